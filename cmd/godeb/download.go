@@ -0,0 +1,179 @@
+// Copyright 2013-2014 Canonical Ltd.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const maxDownloadAttempts = 5
+
+var mirrorFlag string
+
+// mirrorURL rewrites url to be served from the configured mirror, via
+// --mirror or the GODEB_MIRROR environment variable, in place of the
+// upstream dl.google.com host. This is useful where dl.google.com is slow
+// or blocked, such as in China or from some CI providers.
+func mirrorURL(url string) string {
+	mirror := mirrorFlag
+	if mirror == "" {
+		mirror = os.Getenv("GODEB_MIRROR")
+	}
+	if mirror == "" {
+		return url
+	}
+	name := tarballFileName(url)
+	return strings.TrimRight(mirror, "/") + "/" + name
+}
+
+// tarballFileName returns the final path element of a tarball URL.
+func tarballFileName(url string) string {
+	if i := strings.LastIndex(url, "/"); i != -1 {
+		return url[i+1:]
+	}
+	return url
+}
+
+// transientError marks a download error as worth retrying.
+type transientError struct{ err error }
+
+func (e *transientError) Error() string { return e.err.Error() }
+func (e *transientError) Unwrap() error { return e.err }
+
+func isTransient(err error) bool {
+	var t *transientError
+	return errors.As(err, &t)
+}
+
+// downloadTarball fetches url into "name.inprogress", resuming a
+// previously interrupted attempt with an HTTP Range request and retrying
+// transient network errors and 5xx responses with exponential backoff. On
+// success the partial file is renamed to name and returned open for
+// reading.
+func downloadTarball(url, name string) (*os.File, error) {
+	url = mirrorURL(url)
+	partial := name + ".inprogress"
+
+	var lastErr error
+	backoff := 500 * time.Millisecond
+	for attempt := 1; attempt <= maxDownloadAttempts; attempt++ {
+		if attempt > 1 {
+			fmt.Fprintf(os.Stderr, "download attempt %d/%d failed (%v), retrying in %s\n", attempt-1, maxDownloadAttempts, lastErr, backoff)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		err := fetchRange(url, partial)
+		if err == nil {
+			if err := os.Rename(partial, name); err != nil {
+				return nil, err
+			}
+			return os.Open(name)
+		}
+		lastErr = err
+		if !isTransient(err) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("failed to download %s after %d attempts: %w", url, maxDownloadAttempts, lastErr)
+}
+
+// fetchRange appends to partial from wherever a previous attempt left off,
+// issuing a Range request when the file is already non-empty.
+func fetchRange(url, partial string) error {
+	f, err := os.OpenFile(partial, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return &transientError{err}
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if offset > 0 {
+			// Server ignored our Range request; start the file over.
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			if err := f.Truncate(0); err != nil {
+				return err
+			}
+			offset = 0
+		}
+	case http.StatusPartialContent:
+		// Resuming as requested.
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The file on disk is already complete.
+		return nil
+	default:
+		if resp.StatusCode >= 500 {
+			return &transientError{fmt.Errorf("got status code %d", resp.StatusCode)}
+		}
+		return fmt.Errorf("got status code %d", resp.StatusCode)
+	}
+
+	bar := newProgressBar(offset+resp.ContentLength, offset)
+	if _, err := io.Copy(f, io.TeeReader(resp.Body, bar)); err != nil {
+		return &transientError{err}
+	}
+	bar.finish()
+	return nil
+}
+
+// progressBar renders download progress to stderr as bytes are written
+// through it. It implements io.Writer so it can sit in an io.TeeReader.
+type progressBar struct {
+	total       int64
+	transferred int64
+	lastPrint   time.Time
+}
+
+func newProgressBar(total, initial int64) *progressBar {
+	return &progressBar{total: total, transferred: initial}
+}
+
+func (p *progressBar) Write(b []byte) (int, error) {
+	p.transferred += int64(len(b))
+	if now := time.Now(); now.Sub(p.lastPrint) > 200*time.Millisecond {
+		p.print()
+		p.lastPrint = now
+	}
+	return len(b), nil
+}
+
+func (p *progressBar) print() {
+	if p.total <= 0 {
+		fmt.Fprintf(os.Stderr, "\rdownloaded %d bytes", p.transferred)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\r%6.2f%% (%d/%d bytes)", float64(p.transferred)/float64(p.total)*100, p.transferred, p.total)
+}
+
+func (p *progressBar) finish() {
+	p.print()
+	fmt.Fprintln(os.Stderr)
+}
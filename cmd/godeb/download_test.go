@@ -0,0 +1,39 @@
+// Copyright 2013-2014 Canonical Ltd.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTarballFileName(t *testing.T) {
+	cases := []struct {
+		url  string
+		want string
+	}{
+		{"https://dl.google.com/go/go1.22.3.linux-amd64.tar.gz", "go1.22.3.linux-amd64.tar.gz"},
+		{"go1.22.3.linux-amd64.tar.gz", "go1.22.3.linux-amd64.tar.gz"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, tarballFileName(c.url), "url %s", c.url)
+	}
+}
+
+func TestMirrorURL(t *testing.T) {
+	orig := mirrorFlag
+	defer func() { mirrorFlag = orig }()
+
+	mirrorFlag = ""
+	assert.Equal(t, "https://dl.google.com/go/go1.22.3.linux-amd64.tar.gz",
+		mirrorURL("https://dl.google.com/go/go1.22.3.linux-amd64.tar.gz"))
+
+	mirrorFlag = "https://mirrors.example.com/golang/"
+	assert.Equal(t, "https://mirrors.example.com/golang/go1.22.3.linux-amd64.tar.gz",
+		mirrorURL("https://dl.google.com/go/go1.22.3.linux-amd64.tar.gz"))
+
+	mirrorFlag = "https://mirrors.example.com/golang"
+	assert.Equal(t, "https://mirrors.example.com/golang/go1.22.3.linux-amd64.tar.gz",
+		mirrorURL("https://dl.google.com/go/go1.22.3.linux-amd64.tar.gz"))
+}
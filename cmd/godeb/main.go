@@ -9,13 +9,18 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"go/build"
+	"io"
 	"net/http"
 	"os"
 	"os/exec"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -32,8 +37,20 @@ func main() {
 	}
 
 	listCmd.Flags().BoolVarP(&includeAll, "all", "a", false, "Include all versions")
+	listCmd.Flags().BoolVar(&listInstalled, "installed", false, "List installed golang-* packages instead of available versions")
 
-	rootCmd.AddCommand(listCmd, downloadCmd, installCmd, removeCmd)
+	for _, cmd := range []*cobra.Command{downloadCmd, installCmd} {
+		cmd.Flags().BoolVar(&insecureSkipChecksum, "insecure-skip-checksum", false, "Skip SHA-256 verification of the downloaded tarball")
+		cmd.Flags().BoolVar(&minStable, "min-stable", false, "Refuse any release that isn't a stable archive (no beta/rc)")
+		cmd.Flags().StringVar(&mirrorFlag, "mirror", "", "Download tarballs from this base URL instead of dl.google.com (or set GODEB_MIRROR)")
+		cmd.Flags().StringVar(&sourceFlag, "source", "dl", `Where to look up tarballs: "dl" (golang.org/dl) or "local" (already-downloaded files)`)
+		cmd.Flags().StringVar(&fileFlag, "file", "", "Path or glob of local go*.tar.gz files, used with --source local")
+	}
+
+	upgradeCmd.Flags().StringVar(&upgradeChannel, "channel", "stable", `Which release to upgrade to: "stable" (latest non-beta/rc), "patch" (newest patch of the installed minor), or "minor" (newest within the installed major)`)
+	upgradeCmd.Flags().BoolVar(&upgradeDryRun, "dry-run", false, "Print the planned version transition without downloading or installing anything")
+
+	rootCmd.AddCommand(listCmd, downloadCmd, installCmd, removeCmd, useCmd, upgradeCmd)
 	rootCmd.SetHelpCommand(&cobra.Command{Hidden: true})
 	rootCmd.Execute()
 }
@@ -51,11 +68,21 @@ var rootCmd = &cobra.Command{
 
 var includeAll bool
 
+var (
+	insecureSkipChecksum bool
+	minStable            bool
+)
+
+var listInstalled bool
+
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List available Go versions",
 	Args:  cobra.NoArgs,
 	RunE: func(_ *cobra.Command, _ []string) error {
+		if listInstalled {
+			return printInstalledVersions()
+		}
 		tbs, err := tarballs(includeAll)
 		if err != nil {
 			return err
@@ -67,12 +94,150 @@ var listCmd = &cobra.Command{
 	},
 }
 
+// installedPackages returns the dpkg package names (e.g. "golang-1.22") of
+// every installed golang-<major>.<minor> package.
+func installedPackages() ([]string, error) {
+	out, err := exec.Command("dpkg", "-l", "golang-*").Output()
+	if err != nil {
+		// dpkg exits 1 with no stdout when the pattern matches nothing,
+		// e.g. on a machine with no golang-* package installed yet.
+		if _, ok := err.(*exec.ExitError); ok && len(strings.TrimSpace(string(out))) == 0 {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("while listing installed packages: %w", err)
+	}
+	var pkgs []string
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.HasPrefix(fields[0], "ii") || !strings.HasPrefix(fields[1], "golang-") {
+			continue
+		}
+		// Skip other golang-* packages, such as golang-go or golang-1.22-go.
+		if ver := strings.TrimPrefix(fields[1], "golang-"); !strings.Contains(ver, ".") || majorMinor(ver) != ver {
+			continue
+		}
+		pkgs = append(pkgs, fields[1])
+	}
+	return pkgs, nil
+}
+
+// printInstalledVersions prints the version suffix of every installed
+// golang-* package, e.g. "1.22" for a golang-1.22 package.
+func printInstalledVersions() error {
+	pkgs, err := installedPackages()
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		fmt.Println(strings.TrimPrefix(pkg, "golang-"))
+	}
+	return nil
+}
+
+var useCmd = &cobra.Command{
+	Use:   "use <version>",
+	Short: "Switch the active go/gofmt alternatives to an installed version",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		return switchAlternatives(args[0])
+	},
+}
+
+// switchAlternatives points the go and gofmt alternatives at the toolchain
+// installed under /usr/lib/go-<major>.<minor>, as registered by
+// registerAlternatives when the golang-<major>.<minor> package was
+// installed via "godeb install".
+func switchAlternatives(version string) error {
+	for _, name := range []string{"go", "gofmt"} {
+		path := fmt.Sprintf("/usr/lib/go-%s/bin/%s", majorMinor(version), name)
+		args := []string{"update-alternatives", "--set", name, path}
+		if os.Getuid() != 0 {
+			args = append([]string{"sudo"}, args...)
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("while switching %s to version %s: %w", name, version, err)
+		}
+	}
+	return nil
+}
+
+// alternativesPriority ranks a version for update-alternatives --install,
+// so that installing a newer golang-<major>.<minor> package makes it the
+// default "go"/"gofmt" without the user having to run "godeb use".
+func alternativesPriority(version string) string {
+	major, minor, patch := parseVersion(version)
+	return strconv.Itoa(major*100000 + minor*1000 + patch)
+}
+
+// registerAlternatives installs the go and gofmt update-alternatives
+// entries for a newly installed golang-<major>.<minor> package. This is
+// CLI-managed, not a postinst script embedded in the .deb itself: only
+// "godeb install" registers the alternative, so a golang-<major>.<minor>
+// package installed by other means (plain dpkg -i, apt, another host)
+// won't have "go"/"gofmt" wired up until "godeb use <version>" is run.
+func registerAlternatives(version string) error {
+	priority := alternativesPriority(version)
+	for _, name := range []string{"go", "gofmt"} {
+		path := fmt.Sprintf("/usr/lib/go-%s/bin/%s", majorMinor(version), name)
+		args := []string{"update-alternatives", "--install", "/usr/bin/" + name, name, path, priority}
+		if os.Getuid() != 0 {
+			args = append([]string{"sudo"}, args...)
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("while registering %s alternative for %s: %w", name, version, err)
+		}
+	}
+	return nil
+}
+
+// deregisterAlternatives removes the update-alternatives entries
+// registerAlternatives installed. Like registerAlternatives, this is
+// CLI-managed: it only runs as part of "godeb remove", not as a prerm
+// script embedded in the .deb, so purging the package by other means
+// leaves its alternative registered.
+func deregisterAlternatives(version string) error {
+	for _, name := range []string{"go", "gofmt"} {
+		path := fmt.Sprintf("/usr/lib/go-%s/bin/%s", majorMinor(version), name)
+		args := []string{"update-alternatives", "--remove", name, path}
+		if os.Getuid() != 0 {
+			args = append([]string{"sudo"}, args...)
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("while deregistering %s alternative for %s: %w", name, version, err)
+		}
+	}
+	return nil
+}
+
 var removeCmd = &cobra.Command{
-	Use:   "remove",
-	Short: "Remove the installed Go package",
-	Args:  cobra.NoArgs,
-	RunE: func(_ *cobra.Command, _ []string) error {
-		args := []string{"dpkg", "--purge", "go"}
+	Use:   "remove [version]",
+	Short: "Remove one installed golang-<major>.<minor> package, or all of them",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(_ *cobra.Command, cmdArgs []string) error {
+		var pkgs []string
+		if len(cmdArgs) == 1 {
+			pkgs = []string{"golang-" + majorMinor(cmdArgs[0])}
+		} else {
+			var err error
+			pkgs, err = installedPackages()
+			if err != nil {
+				return err
+			}
+		}
+		if len(pkgs) == 0 {
+			return fmt.Errorf("no golang-* package is installed")
+		}
+
+		args := append([]string{"dpkg", "--purge"}, pkgs...)
 		if os.Getuid() != 0 {
 			args = append([]string{"sudo"}, args...)
 		}
@@ -82,6 +247,12 @@ var removeCmd = &cobra.Command{
 		if err := cmd.Run(); err != nil {
 			return fmt.Errorf("while removing go package: %w", err)
 		}
+
+		for _, pkg := range pkgs {
+			if err := deregisterAlternatives(strings.TrimPrefix(pkg, "golang-")); err != nil {
+				return err
+			}
+		}
 		return nil
 	},
 }
@@ -113,22 +284,40 @@ var installCmd = &cobra.Command{
 }
 
 func actionCommand(version string, install bool) error {
-	tbs, err := tarballs(true)
+	ctx := context.Background()
+	source, err := newSource()
 	if err != nil {
 		return err
 	}
-	var url string
+
+	tbs, err := source.List(ctx)
+	if err != nil {
+		return err
+	}
+	var tb *Tarball
 	if version == "" {
-		version = tbs[0].Version
-		url = tbs[0].URL
+		for _, t := range tbs {
+			if minStable && !t.isStable() {
+				continue
+			}
+			tb = t
+			break
+		}
 	} else {
-		for _, tb := range tbs {
-			if version == tb.Version {
-				url = tb.URL
+		for _, t := range tbs {
+			if version == t.Version {
+				tb = t
 				break
 			}
 		}
+		if tb != nil && minStable && !tb.isStable() {
+			return fmt.Errorf("go version %s is not a stable archive release", tb.Version)
+		}
+	}
+	if tb == nil {
+		return fmt.Errorf("no matching Go release found")
 	}
+	version, url := tb.Version, tb.URL
 
 	installed, err := installedDebVersion()
 	if err == errNotInstalled {
@@ -139,26 +328,41 @@ func actionCommand(version string, install bool) error {
 		return fmt.Errorf("go version %s is already installed", version)
 	}
 
+	if tb.Sha256 == "" && !insecureSkipChecksum {
+		return fmt.Errorf("no checksum available for %s; pass --insecure-skip-checksum to install it unverified", url)
+	}
+
 	fmt.Println("processing", url)
-	resp, err := http.Get(url)
+	tarball, err := source.Open(ctx, tb)
 	if err != nil {
-		return fmt.Errorf("failed to download %s: %v", url, err)
-	}
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("got status code %d", resp.StatusCode)
+		return fmt.Errorf("failed to open %s: %v", url, err)
 	}
-	defer resp.Body.Close()
+	defer tarball.Close()
 
-	debName := fmt.Sprintf("go_%s_%s.deb", debVersion(version), debArch())
+	debName := fmt.Sprintf("golang-%s_%s_%s.deb", majorMinor(version), debVersion(version), debArch())
 	deb, err := os.Create(debName + ".inprogress")
 	if err != nil {
 		return fmt.Errorf("cannot create deb: %v", err)
 	}
 	defer deb.Close()
 
-	if err := createDeb(version, resp.Body, deb); err != nil {
+	var body io.Reader = tarball
+	sum := sha256.New()
+	verify := !insecureSkipChecksum && tb.Sha256 != ""
+	if verify {
+		body = io.TeeReader(tarball, sum)
+	}
+
+	if err := createDeb(version, body, deb); err != nil {
+		os.Remove(debName + ".inprogress")
 		return err
 	}
+	if verify {
+		if got := hex.EncodeToString(sum.Sum(nil)); got != tb.Sha256 {
+			os.Remove(debName + ".inprogress")
+			return fmt.Errorf("checksum mismatch for %s: got %s, want %s", url, got, tb.Sha256)
+		}
+	}
 	if err := os.Rename(debName+".inprogress", debName); err != nil {
 		return err
 	}
@@ -175,6 +379,9 @@ func actionCommand(version string, install bool) error {
 		if err := cmd.Run(); err != nil {
 			return fmt.Errorf("while installing go package: %v", err)
 		}
+		if err := registerAlternatives(version); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -182,6 +389,33 @@ func actionCommand(version string, install bool) error {
 type Tarball struct {
 	URL     string
 	Version string
+	Sha256  string
+	Size    int64
+	Kind    string
+}
+
+// isStable reports whether the tarball is an archive release rather
+// than a beta or release candidate.
+func (tb *Tarball) isStable() bool {
+	return tb.Kind == "archive" && !strings.Contains(tb.Version, "beta") && !strings.Contains(tb.Version, "rc")
+}
+
+// majorMinor returns the "X.Y" prefix of a Go version string, e.g. "1.22"
+// for "1.22.1" or "1.22rc1", for use in the golang-<major>.<minor> package
+// name that lets several versions install side by side.
+func majorMinor(version string) string {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return version
+	}
+	minor := parts[1]
+	for i, r := range minor {
+		if r < '0' || r > '9' {
+			minor = minor[:i]
+			break
+		}
+	}
+	return parts[0] + "." + minor
 }
 
 type GolangDlFile struct {
@@ -189,6 +423,9 @@ type GolangDlFile struct {
 	Filename string `json:"filename"`
 	Os       string `json:"os"`
 	Version  string `json:"version"`
+	Sha256   string `json:"sha256"`
+	Size     int64  `json:"size"`
+	Kind     string `json:"kind"`
 }
 
 type GolangDlVersion struct {
@@ -199,8 +436,15 @@ type GolangDlVersion struct {
 // REST API described in https://github.com/golang/website/blob/master/internal/dl/dl.go
 func tarballs(includeAll bool) ([]*Tarball, error) {
 	url := "https://golang.org/dl/?mode=json"
+	if dlURL := os.Getenv("GODEB_DL_URL"); dlURL != "" {
+		url = dlURL
+	}
 	if includeAll {
-		url += "&include=all"
+		if strings.Contains(url, "?") {
+			url += "&include=all"
+		} else {
+			url += "?include=all"
+		}
 	}
 	downloadBaseURL := "https://dl.google.com/go/"
 
@@ -222,6 +466,9 @@ func tarballs(includeAll bool) ([]*Tarball, error) {
 				tbs = append(tbs, &Tarball{
 					Version: strings.TrimPrefix(f.Version, "go"),
 					URL:     downloadBaseURL + f.Filename,
+					Sha256:  f.Sha256,
+					Size:    f.Size,
+					Kind:    f.Kind,
 				})
 				break
 			}
@@ -0,0 +1,41 @@
+// Copyright 2013-2014 Canonical Ltd.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTarballIsStable(t *testing.T) {
+	cases := []struct {
+		tb   Tarball
+		want bool
+	}{
+		{Tarball{Version: "1.22.3", Kind: "archive"}, true},
+		{Tarball{Version: "1.22rc1", Kind: "archive"}, false},
+		{Tarball{Version: "1.22beta1", Kind: "archive"}, false},
+		{Tarball{Version: "1.22.3", Kind: "source"}, false},
+		{Tarball{Version: "1.22.3", Kind: ""}, false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, c.tb.isStable(), "version %s kind %s", c.tb.Version, c.tb.Kind)
+	}
+}
+
+func TestMajorMinor(t *testing.T) {
+	cases := []struct {
+		version string
+		want    string
+	}{
+		{"1.22.3", "1.22"},
+		{"1.22", "1.22"},
+		{"1.22rc1", "1.22"},
+		{"1.22beta1", "1.22"},
+		{"1", "1"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, majorMinor(c.version), "version %s", c.version)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2013-2014 Canonical Ltd.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// Source enumerates the Go tarballs available from some origin and opens
+// one for reading. godeb ships two: the golang.org/dl JSON feed (the
+// original and default) and already-downloaded local files.
+//
+// A GitHub-tags source was considered, but golang/go's tags only have
+// GitHub's auto-generated source-tree snapshots attached, not the prebuilt
+// binary distributions godeb packages; it was dropped until there's an
+// actual binary artifact to pull from GitHub.
+type Source interface {
+	List(ctx context.Context) ([]*Tarball, error)
+	Open(ctx context.Context, tb *Tarball) (io.ReadCloser, error)
+}
+
+var (
+	sourceFlag string
+	fileFlag   string
+)
+
+// newSource builds the Source selected by --source, defaulting to the
+// golang.org/dl JSON feed godeb has always used.
+func newSource() (Source, error) {
+	switch sourceFlag {
+	case "", "dl":
+		return &dlSource{includeAll: true}, nil
+	case "local":
+		if fileFlag == "" {
+			return nil, fmt.Errorf("--source local requires --file <path-or-glob>")
+		}
+		return &localSource{pattern: fileFlag}, nil
+	default:
+		return nil, fmt.Errorf("unknown source %q", sourceFlag)
+	}
+}
+
+// dlSource lists and fetches tarballs from the golang.org/dl JSON feed.
+type dlSource struct {
+	includeAll bool
+}
+
+func (s *dlSource) List(_ context.Context) ([]*Tarball, error) {
+	return tarballs(s.includeAll)
+}
+
+func (s *dlSource) Open(_ context.Context, tb *Tarball) (io.ReadCloser, error) {
+	return downloadTarball(tb.URL, tarballFileName(tb.URL))
+}
+
+// localSource serves already-downloaded go*.tar.gz files from disk,
+// matched by a path or glob, so air-gapped machines can still produce
+// debs without reaching the network.
+type localSource struct {
+	pattern string
+}
+
+// localTarballName matches upstream tarball names such as
+// "go1.22.3.linux-amd64.tar.gz" and captures the version.
+var localTarballName = regexp.MustCompile(`^go([0-9][\w.]*)\.[a-z0-9]+-[a-z0-9]+\.tar\.gz$`)
+
+func (s *localSource) List(_ context.Context) ([]*Tarball, error) {
+	matches, err := filepath.Glob(s.pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no local tarballs match %s", s.pattern)
+	}
+
+	var tbs []*Tarball
+	for _, m := range matches {
+		sub := localTarballName.FindStringSubmatch(filepath.Base(m))
+		if sub == nil {
+			continue
+		}
+		tbs = append(tbs, &Tarball{Version: sub[1], URL: m, Kind: "archive"})
+	}
+	if len(tbs) == 0 {
+		return nil, fmt.Errorf("no file matching %s looks like a go*.tar.gz tarball", s.pattern)
+	}
+
+	sort.Sort(tarballSlice(tbs))
+	return tbs, nil
+}
+
+func (s *localSource) Open(_ context.Context, tb *Tarball) (io.ReadCloser, error) {
+	return os.Open(tb.URL)
+}
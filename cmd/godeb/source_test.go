@@ -0,0 +1,42 @@
+// Copyright 2013-2014 Canonical Ltd.
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalSourceList(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"go1.22.3.linux-amd64.tar.gz",
+		"go1.21.8.linux-amd64.tar.gz",
+		"README.md",
+	} {
+		f, err := os.Create(filepath.Join(dir, name))
+		assert.NoError(t, err)
+		assert.NoError(t, f.Close())
+	}
+
+	src := &localSource{pattern: filepath.Join(dir, "*.tar.gz")}
+	tbs, err := src.List(context.Background())
+	assert.NoError(t, err)
+
+	var versions []string
+	for _, tb := range tbs {
+		versions = append(versions, tb.Version)
+	}
+	assert.Equal(t, []string{"1.22.3", "1.21.8"}, versions)
+}
+
+func TestLocalSourceListNoMatch(t *testing.T) {
+	dir := t.TempDir()
+	src := &localSource{pattern: filepath.Join(dir, "*.tar.gz")}
+	_, err := src.List(context.Background())
+	assert.Error(t, err)
+}
@@ -0,0 +1,133 @@
+// Copyright 2013-2014 Canonical Ltd.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// errAlreadyUpToDate is returned by selectUpgrade when the newest release
+// matching the requested channel is not newer than what's installed.
+var errAlreadyUpToDate = errors.New("already up to date")
+
+var (
+	upgradeChannel string
+	upgradeDryRun  bool
+)
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrade the installed Go toolchain following a channel policy",
+	Args:  cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		return upgradeCommand()
+	},
+}
+
+func upgradeCommand() error {
+	installed, err := installedDebVersion()
+	if err == errNotInstalled {
+		return fmt.Errorf("no golang package is installed; use 'godeb install' instead")
+	} else if err != nil {
+		return err
+	}
+
+	tbs, err := tarballs(true)
+	if err != nil {
+		return err
+	}
+
+	target, err := selectUpgrade(installed, tbs, upgradeChannel)
+	if err == errAlreadyUpToDate {
+		fmt.Println("already up to date at", installed)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s -> %s\n", installed, target.Version)
+	if upgradeDryRun {
+		return nil
+	}
+
+	return actionCommand(target.Version, true)
+}
+
+// selectUpgrade returns the newest stable tarball that installed may
+// upgrade to under channel: "stable" considers every stable release,
+// "patch" is pinned to the installed major.minor, and "minor" is pinned
+// to the installed major. tbs must already be sorted newest-first, as
+// tarballs() returns them, so the first match is the newest one in that
+// channel. It returns errAlreadyUpToDate rather than a downgrade when that
+// newest match isn't actually newer than installed.
+func selectUpgrade(installed string, tbs []*Tarball, channel string) (*Tarball, error) {
+	instMajor, instMinor, instPatch := parseVersion(installed)
+
+	for _, tb := range tbs {
+		if !tb.isStable() {
+			continue
+		}
+		major, minor, patch := parseVersion(tb.Version)
+		switch channel {
+		case "", "stable":
+			// no extra constraint
+		case "patch":
+			if major != instMajor || minor != instMinor {
+				continue
+			}
+		case "minor":
+			if major != instMajor {
+				continue
+			}
+		default:
+			return nil, fmt.Errorf("unknown channel %q", channel)
+		}
+		if !versionGreater(major, minor, patch, instMajor, instMinor, instPatch) {
+			return nil, errAlreadyUpToDate
+		}
+		return tb, nil
+	}
+	return nil, fmt.Errorf("no release found for channel %q", channel)
+}
+
+// versionGreater reports whether a is a later release than b, comparing
+// major, minor, and patch components in that order.
+func versionGreater(aMajor, aMinor, aPatch, bMajor, bMinor, bPatch int) bool {
+	if aMajor != bMajor {
+		return aMajor > bMajor
+	}
+	if aMinor != bMinor {
+		return aMinor > bMinor
+	}
+	return aPatch > bPatch
+}
+
+// parseVersion extracts the numeric major, minor, and patch components of
+// a Go version string such as "1.22.3" or "1.22rc1" (patch 0 for the
+// latter, since rc/beta suffixes aren't numeric patch levels).
+func parseVersion(v string) (major, minor, patch int) {
+	mm := majorMinor(v)
+	parts := strings.SplitN(mm, ".", 2)
+	major, _ = strconv.Atoi(parts[0])
+	if len(parts) > 1 {
+		minor, _ = strconv.Atoi(parts[1])
+	}
+
+	rest := strings.TrimPrefix(strings.TrimPrefix(v, mm), ".")
+	end := len(rest)
+	for i, r := range rest {
+		if r < '0' || r > '9' {
+			end = i
+			break
+		}
+	}
+	if end > 0 {
+		patch, _ = strconv.Atoi(rest[:end])
+	}
+	return
+}
@@ -0,0 +1,61 @@
+// Copyright 2013-2014 Canonical Ltd.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVersion(t *testing.T) {
+	cases := []struct {
+		version string
+		major   int
+		minor   int
+		patch   int
+	}{
+		{"1.22.3", 1, 22, 3},
+		{"1.22", 1, 22, 0},
+		{"1.22rc1", 1, 22, 0},
+		{"1.22beta1", 1, 22, 0},
+		{"1.9.13", 1, 9, 13},
+	}
+	for _, c := range cases {
+		major, minor, patch := parseVersion(c.version)
+		assert.Equal(t, c.major, major, "major of %s", c.version)
+		assert.Equal(t, c.minor, minor, "minor of %s", c.version)
+		assert.Equal(t, c.patch, patch, "patch of %s", c.version)
+	}
+}
+
+func TestSelectUpgrade(t *testing.T) {
+	tbs := []*Tarball{
+		{Version: "1.23.0", Kind: "archive"},
+		{Version: "1.22.6", Kind: "archive"},
+		{Version: "1.22.5rc1", Kind: "archive"},
+		{Version: "1.22.1", Kind: "archive"},
+		{Version: "1.21.10", Kind: "archive"},
+	}
+
+	tb, err := selectUpgrade("1.22.1", tbs, "stable")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.23.0", tb.Version)
+
+	tb, err = selectUpgrade("1.22.1", tbs, "patch")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.22.6", tb.Version)
+
+	tb, err = selectUpgrade("1.22.1", tbs, "minor")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.23.0", tb.Version)
+
+	_, err = selectUpgrade("1.21.10", tbs, "patch")
+	assert.Equal(t, errAlreadyUpToDate, err)
+
+	_, err = selectUpgrade("1.23.0", tbs, "stable")
+	assert.Equal(t, errAlreadyUpToDate, err)
+
+	_, err = selectUpgrade("1.22.1", tbs, "bogus")
+	assert.Error(t, err)
+}